@@ -0,0 +1,157 @@
+package fasttimerwheel
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule 是一个按标准5字段cron表达式（分 时 日 月 周）计算下一次触发时间的
+// Scheduler，可以直接传给 ScheduleRecurring 使用.
+type CronSchedule struct {
+	minute  fieldSet
+	hour    fieldSet
+	day     fieldSet
+	month   fieldSet
+	weekday fieldSet
+
+	// dayStar/weekdayStar 记录日、周字段在表达式里是否写的是`*`（即不限制）.
+	// Vixie cron的规则是：当日、周都被限制时两者取或，否则正常取与，见Next.
+	dayStar     bool
+	weekdayStar bool
+}
+
+// fieldSet 是一个cron字段允许的取值集合，bit i 为true表示值i是允许的.
+type fieldSet [62]bool
+
+var cronFieldRanges = [5][2]int{
+	{0, 59}, // minute
+	{0, 23}, // hour
+	{1, 31}, // day of month
+	{1, 12}, // month
+	{0, 7},  // day of week, 0和7都表示周日
+}
+
+// ParseCron 解析标准5字段的cron表达式（分 时 日 月 周），支持 `*`、`*/n`、
+// 列表 `a,b,c`、区间 `a-b` 以及区间加步长 `a-b/n`.
+func ParseCron(expr string) (*CronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("fasttimerwheel: cron expression must have 5 fields, got %d: %q", len(fields), expr)
+	}
+
+	sets := make([]fieldSet, 5)
+	for i, f := range fields {
+		set, err := parseCronField(f, cronFieldRanges[i][0], cronFieldRanges[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("fasttimerwheel: invalid cron field %q: %w", f, err)
+		}
+		sets[i] = set
+	}
+
+	cs := &CronSchedule{
+		minute:      sets[0],
+		hour:        sets[1],
+		day:         sets[2],
+		month:       sets[3],
+		weekday:     sets[4],
+		dayStar:     fields[2] == "*",
+		weekdayStar: fields[4] == "*",
+	}
+	// 0和7都代表周日
+	if cs.weekday[0] {
+		cs.weekday[7] = true
+	}
+	if cs.weekday[7] {
+		cs.weekday[0] = true
+	}
+	return cs, nil
+}
+
+func parseCronField(field string, min, max int) (fieldSet, error) {
+	var set fieldSet
+
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step, err := splitStep(part)
+		if err != nil {
+			return set, err
+		}
+
+		lo, hi := min, max
+		if rangePart != "*" {
+			lo, hi, err = splitRange(rangePart, min, max)
+			if err != nil {
+				return set, err
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			if v < 0 || v >= len(set) {
+				return set, fmt.Errorf("value %d out of range", v)
+			}
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+func splitStep(part string) (rangePart string, step int, err error) {
+	pieces := strings.SplitN(part, "/", 2)
+	if len(pieces) == 1 {
+		return pieces[0], 1, nil
+	}
+	step, err = strconv.Atoi(pieces[1])
+	if err != nil || step <= 0 {
+		return "", 0, fmt.Errorf("invalid step in %q", part)
+	}
+	return pieces[0], step, nil
+}
+
+func splitRange(part string, min, max int) (lo, hi int, err error) {
+	bounds := strings.SplitN(part, "-", 2)
+	lo, err = strconv.Atoi(bounds[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid value %q", bounds[0])
+	}
+	if len(bounds) == 1 {
+		return lo, lo, nil
+	}
+	hi, err = strconv.Atoi(bounds[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid value %q", bounds[1])
+	}
+	if lo < min || hi > max || lo > hi {
+		return 0, 0, fmt.Errorf("range %q out of bounds [%d,%d]", part, min, max)
+	}
+	return lo, hi, nil
+}
+
+// maxCronSearch 是Next向未来搜索匹配时间的上限，避免表达式不可能匹配
+// (比如 2月30日) 时无限循环.
+const maxCronSearch = 4 * 366 * 24 * time.Hour
+
+// Next 实现 Scheduler，返回prev之后最近一个匹配cron表达式的整分钟时刻，
+// 找不到（搜索超过maxCronSearch）则返回零值.
+func (cs *CronSchedule) Next(prev time.Time) time.Time {
+	t := prev.Truncate(time.Minute).Add(time.Minute)
+	deadline := prev.Add(maxCronSearch)
+
+	for t.Before(deadline) {
+		if cs.month[int(t.Month())] && cs.dayMatches(t) &&
+			cs.hour[t.Hour()] && cs.minute[t.Minute()] {
+			return t
+		}
+		t = t.Add(time.Minute)
+	}
+	return time.Time{}
+}
+
+// dayMatches 按标准5字段cron的规则判断t是否匹配日、周字段：两者都限制时取或
+// （命中其一即可），否则正常取与（`*`字段本身恒为true，不影响结果）.
+func (cs *CronSchedule) dayMatches(t time.Time) bool {
+	if !cs.dayStar && !cs.weekdayStar {
+		return cs.day[t.Day()] || cs.weekday[int(t.Weekday())]
+	}
+	return cs.day[t.Day()] && cs.weekday[int(t.Weekday())]
+}
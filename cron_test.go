@@ -0,0 +1,40 @@
+package fasttimerwheel
+
+import (
+	"testing"
+	"time"
+)
+
+// TestCronScheduleDayOrWeekday 验证当日、周字段都被限制时，标准5字段cron按照
+// Vixie cron的规则取"或"而不是"与"：命中13号或周五任意一个即可.
+func TestCronScheduleDayOrWeekday(t *testing.T) {
+	cs, err := ParseCron("0 0 13 * 5")
+	if err != nil {
+		t.Fatalf("ParseCron: %v", err)
+	}
+
+	prev := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	next := cs.Next(prev)
+
+	want := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC) // 第一个周五，早于13号
+	if !next.Equal(want) {
+		t.Fatalf("Next(%v) = %v, want %v", prev, next, want)
+	}
+}
+
+// TestCronScheduleDayAndWeekdayStar 验证日、周字段只有一个被限制时仍然正常取与
+// （`*`的那个字段恒为true）.
+func TestCronScheduleDayAndWeekdayStar(t *testing.T) {
+	cs, err := ParseCron("0 0 13 * *")
+	if err != nil {
+		t.Fatalf("ParseCron: %v", err)
+	}
+
+	prev := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	next := cs.Next(prev)
+
+	want := time.Date(2026, 1, 13, 0, 0, 0, 0, time.UTC)
+	if !next.Equal(want) {
+		t.Fatalf("Next(%v) = %v, want %v", prev, next, want)
+	}
+}
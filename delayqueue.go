@@ -0,0 +1,106 @@
+package fasttimerwheel
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// delayQueueItem 是delayQueue中的一项，表示某个槽的到期（绝对）时间.
+type delayQueueItem struct {
+	expiration time.Time
+	index      int
+}
+
+// delayQueueHeap 是一个按expiration排序的最小堆.
+type delayQueueHeap []*delayQueueItem
+
+func (h delayQueueHeap) Len() int           { return len(h) }
+func (h delayQueueHeap) Less(i, j int) bool { return h[i].expiration.Before(h[j].expiration) }
+func (h delayQueueHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *delayQueueHeap) Push(x interface{}) {
+	*h = append(*h, x.(*delayQueueItem))
+}
+
+func (h *delayQueueHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return item
+}
+
+// delayQueue 是一个按到期时间排序的最小堆，用来驱动时间轮按需步进：只有真正
+// 非空的槽才会入队 (offer)，空槽不会产生任何工作；一个槽只会被enqueue一次，
+// 出队 (pop) 时会阻塞到堆顶到期为止，由调用方的goroutine负责推动时间轮前进.
+type delayQueue struct {
+	mu     sync.Mutex
+	items  delayQueueHeap
+	wakeup chan struct{}
+	closed chan struct{}
+}
+
+func newDelayQueue() *delayQueue {
+	return &delayQueue{
+		wakeup: make(chan struct{}, 1),
+		closed: make(chan struct{}),
+	}
+}
+
+// offer 把一个槽的到期时间放入队列；如果它比当前堆顶更早，唤醒正在pop中的等待者
+// 让它重新计算等待时间.
+func (q *delayQueue) offer(index int, expiration time.Time) {
+	q.mu.Lock()
+	item := &delayQueueItem{expiration: expiration, index: index}
+	heap.Push(&q.items, item)
+	earliest := q.items[0] == item
+	q.mu.Unlock()
+
+	if earliest {
+		select {
+		case q.wakeup <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// pop 阻塞直到堆顶到期，返回到期的槽下标；队列被close后返回 ok=false.
+func (q *delayQueue) pop() (index int, expiration time.Time, ok bool) {
+	for {
+		q.mu.Lock()
+		if len(q.items) == 0 {
+			q.mu.Unlock()
+			select {
+			case <-q.wakeup:
+				continue
+			case <-q.closed:
+				return 0, time.Time{}, false
+			}
+		}
+
+		item := q.items[0]
+		wait := time.Until(item.expiration)
+		if wait <= 0 {
+			heap.Pop(&q.items)
+			q.mu.Unlock()
+			return item.index, item.expiration, true
+		}
+		q.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-q.wakeup:
+			timer.Stop()
+		case <-q.closed:
+			timer.Stop()
+			return 0, time.Time{}, false
+		}
+	}
+}
+
+func (q *delayQueue) close() {
+	close(q.closed)
+}
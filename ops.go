@@ -0,0 +1,86 @@
+package fasttimerwheel
+
+import "time"
+
+// RemoveAll 移除所有满足predicate的数据，返回实际移除的数量，用来做批量驱逐
+// （比如关闭时drain掉所有还没到期的数据）.
+func (tw *TimerWheel) RemoveAll(predicate func(data interface{}) bool) int {
+	tw.idSlotMap.Lock()
+	matched := make([]interface{}, 0)
+	for data := range tw.idSlotMap.Data {
+		if predicate(data) {
+			matched = append(matched, data)
+		}
+	}
+	tw.idSlotMap.Unlock()
+
+	count := 0
+	for _, data := range matched {
+		if tw.removeTimer(data) {
+			count++
+		}
+	}
+	return count
+}
+
+// Len 返回当前还在时间轮里（包括级联到上级时间轮）等待触发的数据总数.
+func (tw *TimerWheel) Len() int {
+	tw.idSlotMap.RLock()
+	defer tw.idSlotMap.RUnlock()
+	return len(tw.idSlotMap.Data)
+}
+
+// Peek 返回在at时刻（含）之前到期的所有数据，用来在不移除数据的情况下排查
+// 哪些条目卡住没有被处理.
+func (tw *TimerWheel) Peek(at time.Time) []interface{} {
+	var result []interface{}
+	tw.Range(func(data interface{}, fireAt time.Time) bool {
+		if !fireAt.After(at) {
+			result = append(result, data)
+		}
+		return true
+	})
+	return result
+}
+
+// Range 按照大致的到期先后顺序遍历时间轮里所有的数据，f返回false时停止遍历.
+// 层级时间轮会先遍历当前层（按槽的循环顺序，从CurrentIndex开始），再递归遍历
+// overflowWheel. 级联上去的数据通常比当前层的数据更晚到期，但不保证：当前层推进后
+// 新放入的数据可能晚于上级时间轮里的数据；同一个槽内的遍历顺序也不确定.
+func (tw *TimerWheel) Range(f func(data interface{}, fireAt time.Time) bool) {
+	if !tw.rangeLevel(f) {
+		return
+	}
+
+	tw.overflowMu.Lock()
+	overflow := tw.overflowWheel
+	tw.overflowMu.Unlock()
+	if overflow != nil {
+		overflow.Range(f)
+	}
+}
+
+func (tw *TimerWheel) rangeLevel(f func(data interface{}, fireAt time.Time) bool) bool {
+	tw.posMu.Lock()
+	start := tw.CurrentIndex
+	tw.posMu.Unlock()
+
+	for i := 0; i < tw.slotCount; i++ {
+		idx := (start + i) % tw.slotCount
+		slot := tw.slots[idx]
+
+		slot.RLock()
+		snapshot := make(map[interface{}]time.Time, len(slot.Data))
+		for data, at := range slot.Data {
+			snapshot[data] = at
+		}
+		slot.RUnlock()
+
+		for data, at := range snapshot {
+			if !f(data, at) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,107 @@
+// Package ratelimit 基于 fasttimerwheel.TimerWheel 实现了一个漏桶限流器.
+//
+// 和 golang.org/x/time/rate 或 uber-go/ratelimit 不同，令牌的定时补充复用了
+// 调用方传入的同一个TimerWheel，而不是每个LeakyBucket单独起一个ticker/goroutine，
+// 因此可以支撑数以百万计的per-connection限流器.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/smallnest/fasttimerwheel"
+)
+
+// ErrClosed 在LeakyBucket被Close之后再Acquire时返回.
+var ErrClosed = errors.New("ratelimit: leaky bucket closed")
+
+// LeakyBucket 是一个基于TimerWheel的漏桶限流器.
+type LeakyBucket struct {
+	capacity int
+	rate     float64
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	tokens int
+	closed bool
+
+	timer *fasttimerwheel.Timer
+}
+
+// NewLeakyBucket 创建一个容量为capacity、以rate（每秒令牌数）补充令牌的漏桶，
+// 令牌补充使用tw这个时间轮上的一个recurring任务驱动，间隔为1/rate.
+func NewLeakyBucket(tw *fasttimerwheel.TimerWheel, capacity int, rate float64) *LeakyBucket {
+	b := &LeakyBucket{
+		capacity: capacity,
+		rate:     rate,
+		tokens:   capacity,
+	}
+	b.cond = sync.NewCond(&b.mu)
+
+	interval := time.Duration(float64(time.Second) / rate)
+	b.timer = tw.ScheduleRecurring(fasttimerwheel.EveryScheduler{D: interval}, b.refill)
+	return b
+}
+
+func (b *LeakyBucket) refill() {
+	b.mu.Lock()
+	if b.tokens < b.capacity {
+		b.tokens++
+	}
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
+
+// TryAcquire 尝试立刻获取一个令牌，成功返回true，没有可用令牌时返回false，不会阻塞.
+func (b *LeakyBucket) TryAcquire() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.tokens <= 0 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Acquire 阻塞直到获取到一个令牌或者ctx被取消/超时.
+func (b *LeakyBucket) Acquire(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			b.cond.Broadcast()
+		case <-done:
+		}
+	}()
+	defer close(done)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for {
+		if b.closed {
+			return ErrClosed
+		}
+		if b.tokens > 0 {
+			b.tokens--
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		b.cond.Wait()
+	}
+}
+
+// Close 停止令牌补充并唤醒所有等待者.
+func (b *LeakyBucket) Close() {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+
+	b.timer.Stop()
+	b.cond.Broadcast()
+}
@@ -0,0 +1,34 @@
+package fasttimerwheel
+
+import "time"
+
+// Scheduler 描述一个重复调度策略.
+// Next 根据上一次触发的时间prev计算下一次应该触发的时间，返回零值表示不再触发.
+type Scheduler interface {
+	Next(prev time.Time) time.Time
+}
+
+// EveryScheduler 是一个固定间隔触发的Scheduler.
+type EveryScheduler struct {
+	D time.Duration
+}
+
+// Next 实现 Scheduler.
+func (s EveryScheduler) Next(prev time.Time) time.Time {
+	return prev.Add(s.D)
+}
+
+// ScheduleRecurring 在 s.Next 返回的时间重复调用f，每次触发后都会再次调用
+// s.Next 计算下一次时间并重新调度（如果需要会级联到上一级时间轮），
+// 直到 s.Next 返回零值为止. 这样heartbeat/健康检查类的任务不再需要在
+// expiredDataFunc里手动重新schedule.
+func (tw *TimerWheel) ScheduleRecurring(s Scheduler, f func()) *Timer {
+	t := &Timer{tw: tw, fn: f, scheduler: s}
+
+	next := s.Next(time.Now())
+	if !next.IsZero() {
+		t.at = next
+		tw.ScheduleAt(next, t)
+	}
+	return t
+}
@@ -0,0 +1,129 @@
+package fasttimerwheel
+
+import (
+	"sync"
+	"time"
+)
+
+// Timer 代表通过 AfterFunc/ScheduleFunc 注册到时间轮上的一个回调，
+// 用法和 time.Timer 类似，但底层共享同一个 TimerWheel 的槽位，
+// 不会为每个Timer单独开goroutine/堆元素.
+type Timer struct {
+	tw    *TimerWheel
+	fn    func()
+	at    time.Time
+	mu    sync.Mutex
+	fired bool
+	// stopped 为true表示Stop已经被调用，invoke时不应该再触发fn.
+	stopped bool
+	// scheduler非nil时表示这是一个通过ScheduleRecurring注册的重复任务，
+	// 每次触发后都会调用scheduler.Next重新计算下一次时间并重新调度.
+	scheduler Scheduler
+}
+
+// invoke 在Timer到期时被调用，f在它自己的goroutine里执行，和time.AfterFunc一致.
+func (t *Timer) invoke() {
+	t.mu.Lock()
+	if t.stopped {
+		t.mu.Unlock()
+		return
+	}
+	t.fired = true
+	t.mu.Unlock()
+
+	t.fn()
+
+	if t.scheduler != nil {
+		t.rescheduleRecurring()
+	}
+}
+
+// rescheduleRecurring 在一次重复任务触发之后，向scheduler要下一次的时间并重新
+// ScheduleAt，scheduler.Next返回零值或者Timer已经被Stop则不再继续.
+func (t *Timer) rescheduleRecurring() {
+	t.mu.Lock()
+	if t.stopped {
+		t.mu.Unlock()
+		return
+	}
+	next := t.scheduler.Next(time.Now())
+	if next.IsZero() {
+		t.mu.Unlock()
+		return
+	}
+	t.fired = false
+	t.at = next
+	t.mu.Unlock()
+
+	t.tw.ScheduleAt(next, t)
+}
+
+// Stop 阻止Timer触发，如果Timer已经触发或者已经被Stop过，返回false.
+func (t *Timer) Stop() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.stopped {
+		return false
+	}
+	// 即使已经触发也要标记stopped，这样正在执行回调的重复任务不会再被重新调度.
+	t.stopped = true
+	if t.fired {
+		return false
+	}
+	return t.tw.removeTimer(t)
+}
+
+// Reset 让Timer在d之后重新触发一次，如果Timer之前还在等待触发，返回true，
+// 等价于demo里heartbeat场景手动Remove再ScheduleAt的写法.
+func (t *Timer) Reset(d time.Duration) bool {
+	t.mu.Lock()
+	active := !t.stopped && !t.fired
+	t.mu.Unlock()
+
+	if active {
+		t.tw.removeTimer(t)
+	}
+
+	t.mu.Lock()
+	t.stopped = false
+	t.fired = false
+	t.at = time.Now().Add(d)
+	at := t.at
+	t.mu.Unlock()
+
+	t.tw.ScheduleAt(at, t)
+	return active
+}
+
+// AfterFunc 在d之后调用f，返回的Timer可以用来Stop或者Reset，用法和time.AfterFunc一致.
+func (tw *TimerWheel) AfterFunc(d time.Duration, f func()) *Timer {
+	return tw.ScheduleFunc(time.Now().Add(d), f)
+}
+
+// ScheduleFunc 在at时刻调用f.
+func (tw *TimerWheel) ScheduleFunc(at time.Time, f func()) *Timer {
+	t := &Timer{tw: tw, fn: f, at: at}
+	tw.ScheduleAt(at, t)
+	return t
+}
+
+// removeTimer 和 Remove 做的事情一样，但会额外告诉调用者data当时是否确实还在
+// 时间轮里，Timer.Stop/Reset需要这个信息来判断自己是否抢在了fire之前.
+func (tw *TimerWheel) removeTimer(data interface{}) bool {
+	tw.idSlotMap.Lock()
+	ref, ok := tw.idSlotMap.Data[data]
+	if ok {
+		delete(tw.idSlotMap.Data, data)
+	}
+	tw.idSlotMap.Unlock()
+	if !ok {
+		return false
+	}
+
+	slot := ref.wheel.slots[ref.index]
+	slot.Lock()
+	delete(slot.Data, data)
+	slot.Unlock()
+	return true
+}
@@ -17,51 +17,116 @@ var (
 
 // TimerWheel 时间轮.
 type TimerWheel struct {
-	tickTime         time.Duration
-	slotCount        int
-	ticker           *time.Ticker
+	tickTime  time.Duration
+	slotCount int
+	ticker    *time.Ticker
+
+	// posMu 保护 CurrentStartTime/CurrentIndex：它们由step/fireSlot等驱动
+	// 循环写入，同时被ScheduleAt等并发读取，没有它会产生数据竞争.
+	posMu            sync.Mutex
 	CurrentStartTime time.Time
 	CurrentIndex     int
-	slots            []Slot
-	maxSlotSize      int
-	idSlotMap        *SyncIntMap
-	expiredDataFunc  ExpiredDataFunc
+
+	slots           []Slot
+	maxSlotSize     int
+	idSlotMap       *SyncSlotMap
+	expiredDataFunc ExpiredDataFunc
+
+	// maxLevel 是这个时间轮还能向上级联的层数，0表示这是最顶层，
+	// 调度超出当前时间轮范围的数据时会返回 ErrSchedulePastHighThreshold.
+	maxLevel int
+	// overflowWheel 是懒创建的上一级时间轮，tickTime 等于当前时间轮的完整区间
+	// (tickTime * slotCount)，用于承载超出当前时间轮范围的数据.
+	overflowWheel *TimerWheel
+	overflowMu    sync.Mutex
+
+	// useDelayQueue 为true时不使用ticker驱动，而是用delayQueue在需要时唤醒，
+	// 见 WithDelayQueue.
+	useDelayQueue bool
+	delayQueue    *delayQueue
+	started       bool
+
+	// cascading 为true表示这是通过ensureOverflowWheel懒创建的上一级时间轮.
+	// 和叶子时间轮不同，它的槽要在覆盖的区间"开始"时就过期下沉到子时间轮，
+	// 而不是"结束"时才下沉，否则下沉时数据的到期时间基本都已经过去了.
+	cascading bool
 }
 
-// Slot 时间槽.
-type Slot *SyncBoolMap
+// Slot 时间槽，记录槽内的数据及其到期时间.
+// 用类型别名而不是具名类型，这样才能继承*SyncTimeMap内嵌sync.RWMutex的方法集
+// （Lock/Unlock/RLock/RUnlock），具名类型会得到一个空方法集.
+type Slot = *SyncTimeMap
 
 // ExpiredDataFunc 处理过期数据的函数.
 // start 是过期的时间槽的起始时间.
 // slot 是过期的时间槽.
 type ExpiredDataFunc func(start time.Time, slot Slot)
 
+// Option 是创建 TimerWheel 时的可选配置.
+type Option func(*TimerWheel)
+
+// WithDelayQueue 让时间轮用一个delay queue驱动，而不是固定间隔的ticker：
+// 空槽不占用任何资源，只有槽里第一次放入数据时才会把它的到期时间入队，
+// 由一个阻塞等待下一个到期时间的goroutine负责推动时间轮前进，避免了
+// ticker方式下空转带来的CPU浪费，也不会再出现处理时间间隔超长的问题.
+func WithDelayQueue() Option {
+	return func(tw *TimerWheel) {
+		tw.useDelayQueue = true
+	}
+}
+
 // New 创建一个时间轮.
 // tickTime 是时间轮检查的时间间隔，也是过期数据超过过期时间还未处理的最大时间间隔.
 // slotCount 是时间轮上的槽数.
 // maxSlotSize 单个槽中可存放的数据数， 0 代表不做限制，可以任意存放.
 // expiredDataFunc 数据过期后要要调用的函数.
-func New(tickTime time.Duration, slotCount int, maxSlotSize int, expiredDataFunc ExpiredDataFunc) *TimerWheel {
+func New(tickTime time.Duration, slotCount int, maxSlotSize int, expiredDataFunc ExpiredDataFunc, opts ...Option) *TimerWheel {
 	tw := &TimerWheel{
 		tickTime:        tickTime,
 		slotCount:       slotCount,
 		slots:           make([]Slot, slotCount),
 		maxSlotSize:     maxSlotSize,
-		idSlotMap:       &SyncIntMap{Data: make(map[interface{}]int)},
+		idSlotMap:       &SyncSlotMap{Data: make(map[interface{}]slotRef)},
 		expiredDataFunc: expiredDataFunc,
 	}
 
+	for _, opt := range opts {
+		opt(tw)
+	}
+
 	for i := 0; i < slotCount; i++ {
-		tw.slots[i] = Slot(&SyncBoolMap{Data: make(map[interface{}]bool)})
+		tw.slots[i] = Slot(&SyncTimeMap{Data: make(map[interface{}]time.Time)})
 	}
 	return tw
 }
 
+// NewHierarchical 创建一个层级时间轮（参考Kafka/Netty的hierarchical timing wheel）.
+// tick 是最底层时间轮的滴答间隔，slotCount 是每一级的槽数，levels 是级联的层数，
+// maxSlotSize 和 expiredDataFunc 与 New 含义相同：maxSlotSize 对每一级时间轮都生效，
+// expiredDataFunc 只作用于最底层时间轮.
+// 整个层级时间轮可以调度的最大延迟约为 tick * slotCount^levels，插入、删除仍然是
+// O(1)，且只在真正需要更高一级range时才会懒创建上级时间轮 (overflowWheel)，不会
+// 预先分配 levels 层的内存.
+func NewHierarchical(tick time.Duration, slotCount, levels, maxSlotSize int, expiredDataFunc ExpiredDataFunc) *TimerWheel {
+	tw := New(tick, slotCount, maxSlotSize, expiredDataFunc)
+	tw.maxLevel = levels - 1
+	return tw
+}
+
 // Start 启动
 func (tw *TimerWheel) Start() {
-	tw.ticker = time.NewTicker(tw.tickTime)
+	tw.posMu.Lock()
 	tw.CurrentStartTime = time.Now()
+	tw.posMu.Unlock()
+	tw.started = true
 
+	if tw.useDelayQueue {
+		tw.delayQueue = newDelayQueue()
+		go tw.runDelayQueue()
+		return
+	}
+
+	tw.ticker = time.NewTicker(tw.tickTime)
 	go func() {
 		for range tw.ticker.C {
 			tw.step()
@@ -71,23 +136,43 @@ func (tw *TimerWheel) Start() {
 
 // Stop 停止时间轮的处理.
 func (tw *TimerWheel) Stop() {
-	tw.ticker.Stop()
-}
+	if tw.useDelayQueue {
+		tw.delayQueue.close()
+	} else {
+		tw.ticker.Stop()
+	}
 
-func (tw *TimerWheel) step() {
-	// 单个ticker中调用，索引的步进不会有并发的问题
-	start := tw.CurrentStartTime
+	tw.overflowMu.Lock()
+	overflow := tw.overflowWheel
+	tw.overflowMu.Unlock()
+	if overflow != nil {
+		overflow.Stop()
+	}
+}
 
-	expiredIndex := tw.CurrentIndex
-	tw.CurrentIndex = tw.CurrentIndex + 1
-	if tw.CurrentIndex >= tw.slotCount {
-		tw.CurrentIndex = 0
+// runDelayQueue 是delayQueue驱动模式下的推进循环：阻塞等待下一个非空槽到期，
+// 到期后直接把 CurrentIndex/CurrentStartTime 推进到那个槽，然后触发它过期.
+func (tw *TimerWheel) runDelayQueue() {
+	for {
+		index, expiration, ok := tw.delayQueue.pop()
+		if !ok {
+			return
+		}
+		if tw.cascading {
+			tw.fireCascadingSlot(index, expiration)
+		} else {
+			tw.fireSlot(index, expiration)
+		}
 	}
-	tw.CurrentStartTime = time.Now()
+}
 
-	expiredSlot := tw.slots[expiredIndex]
+// deliverSlot 投递下标为index的槽：清空它、清理idSlotMap、触发其中的Timer回调，
+// 最后调用expiredDataFunc（如果设置了的话）. start是投递时要报给expiredDataFunc的
+// 时间戳.
+func (tw *TimerWheel) deliverSlot(index int, start time.Time) {
+	expiredSlot := tw.slots[index]
 	expiredSlot.Lock()
-	tw.slots[expiredIndex] = Slot(&SyncBoolMap{Data: make(map[interface{}]bool)})
+	tw.slots[index] = Slot(&SyncTimeMap{Data: make(map[interface{}]time.Time)})
 	expiredSlot.Unlock()
 
 	tw.idSlotMap.Lock()
@@ -97,23 +182,139 @@ func (tw *TimerWheel) step() {
 	tw.idSlotMap.Unlock()
 
 	// 此时应该没有并发访问expiredSlot的问题
-	tw.expiredDataFunc(start, expiredSlot)
+	// cascading的上级时间轮只是把数据级联下沉到子时间轮（见cascadeExpired），
+	// 这里还没到真正到期的时刻，不能直接调用Timer回调，否则会在级联时提前触发
+	// 一次，到期时还会再触发第二次.
+	if !tw.cascading {
+		for data := range expiredSlot.Data {
+			if t, ok := data.(*Timer); ok {
+				go t.invoke()
+			}
+		}
+	}
+	if tw.expiredDataFunc != nil {
+		tw.expiredDataFunc(start, expiredSlot)
+	}
+}
+
+// fireSlot 是叶子层真正投递数据的过期方式：下标为index的槽覆盖的区间此刻(next)
+// 才结束，投递的同时把时间轮推进到next.
+func (tw *TimerWheel) fireSlot(index int, next time.Time) {
+	tw.posMu.Lock()
+	start := tw.CurrentStartTime
+	tw.CurrentIndex = (index + 1) % tw.slotCount
+	tw.CurrentStartTime = next
+	tw.posMu.Unlock()
+	tw.deliverSlot(index, start)
+}
+
+// fireCascadingSlot 是上级（overflow）时间轮的过期方式：下标为index的槽覆盖的
+// 区间此刻(now)才刚刚开始，所以要在这一刻就把它级联下沉，这样下沉后子时间轮里
+// 还有完整的一轮可以精确摆放，而不是等区间结束时才下沉（那时数据基本都已经到期）.
+func (tw *TimerWheel) fireCascadingSlot(index int, now time.Time) {
+	tw.posMu.Lock()
+	tw.CurrentIndex = index
+	tw.CurrentStartTime = now
+	tw.posMu.Unlock()
+	tw.deliverSlot(index, now)
+}
+
+func (tw *TimerWheel) step() {
+	// 只有推进时间轮的那个goroutine会调用step，但CurrentIndex仍然会被
+	// ScheduleAt等并发读取，因此读取时也要经过posMu.
+	tw.posMu.Lock()
+	current := tw.CurrentIndex
+	tw.posMu.Unlock()
+
+	if tw.cascading {
+		index := (current + 1) % tw.slotCount
+		tw.fireCascadingSlot(index, time.Now())
+		return
+	}
+	tw.fireSlot(current, time.Now())
+}
+
+// cascadeExpired 是上级时间轮的 expiredDataFunc，把上级槽里到期的数据重新
+// ScheduleAt 回当前（更细粒度的）时间轮，让它落到正确的低层槽位中（bucket cascading）.
+func (tw *TimerWheel) cascadeExpired(start time.Time, slot Slot) {
+	for data, at := range slot.Data {
+		if err := tw.ScheduleAt(at, data); err != nil {
+			// 只有系统被拖慢、下沉时数据的到期时间已经过去才会走到这里：直接塞进
+			// 子时间轮当前的槽，交给下一次投递正常处理（包括Timer回调），而不是
+			// 在这里另起一份投递逻辑、并在expiredDataFunc为nil时把数据悄悄丢弃.
+			tw.forceInsertCurrent(data, at)
+		}
+	}
+}
+
+// forceInsertCurrent 把data直接放进时间轮当前的槽，不做时间校验，用于级联下沉时
+// 发现数据已经来不及正常ScheduleAt的兜底场景.
+func (tw *TimerWheel) forceInsertCurrent(data interface{}, at time.Time) {
+	tw.posMu.Lock()
+	index := tw.CurrentIndex
+	tw.posMu.Unlock()
+
+	slot := tw.slots[index]
+	slot.Lock()
+	slot.Data[data] = at
+	slot.Unlock()
+
+	tw.idSlotMap.Lock()
+	tw.idSlotMap.Data[data] = slotRef{wheel: tw, index: index}
+	tw.idSlotMap.Unlock()
+}
+
+// ensureOverflowWheel 懒创建上一级时间轮，它的 tickTime 等于当前时间轮的完整区间.
+func (tw *TimerWheel) ensureOverflowWheel() *TimerWheel {
+	tw.overflowMu.Lock()
+	defer tw.overflowMu.Unlock()
+
+	if tw.overflowWheel == nil {
+		var opts []Option
+		if tw.useDelayQueue {
+			opts = append(opts, WithDelayQueue())
+		}
+		overflow := New(tw.tickTime*time.Duration(tw.slotCount), tw.slotCount, tw.maxSlotSize, nil, opts...)
+		overflow.maxLevel = tw.maxLevel - 1
+		overflow.idSlotMap = tw.idSlotMap
+		overflow.expiredDataFunc = tw.cascadeExpired
+		overflow.cascading = true
+
+		tw.posMu.Lock()
+		overflow.CurrentStartTime = tw.CurrentStartTime
+		tw.posMu.Unlock()
+
+		if tw.started {
+			overflow.Start()
+		}
+		tw.overflowWheel = overflow
+	}
+	return tw.overflowWheel
 }
 
 // ScheduleAt schedule一个数据，将在at时间过期.
-// TimerWheel 会将它放入一个合适的时间槽中.
+// TimerWheel 会将它放入一个合适的时间槽中，如果 at 超出了当前时间轮能表示的范围，
+// 并且这个时间轮允许向上级联（见 NewHierarchical），则会级联到上一级时间轮.
 func (tw *TimerWheel) ScheduleAt(at time.Time, data interface{}) error {
-	if at.Before(tw.CurrentStartTime) {
+	tw.posMu.Lock()
+	currentStart := tw.CurrentStartTime
+	currentIndex := tw.CurrentIndex
+	tw.posMu.Unlock()
+
+	if at.Before(currentStart) {
 		return ErrScheduleInPast
 	}
 
-	d := at.UnixNano() - tw.CurrentStartTime.UnixNano()
+	d := at.UnixNano() - currentStart.UnixNano()
 	steps := int(d / int64(tw.tickTime))
 	if steps >= tw.slotCount {
-		return ErrSchedulePastHighThreshold
+		if tw.maxLevel <= 0 {
+			return ErrSchedulePastHighThreshold
+		}
+		return tw.ensureOverflowWheel().ScheduleAt(at, data)
 	}
 
-	index := (tw.CurrentIndex + steps) % tw.slotCount
+	index := (currentIndex + steps) % tw.slotCount
 	slot := tw.slots[index]
 
 	slot.Lock()
@@ -121,11 +322,22 @@ func (tw *TimerWheel) ScheduleAt(at time.Time, data interface{}) error {
 		slot.Unlock()
 		return ErrSlotIsFull
 	}
-	slot.Data[data] = true
+	wasEmpty := len(slot.Data) == 0
+	slot.Data[data] = at
 	slot.Unlock()
 
+	if tw.useDelayQueue && wasEmpty {
+		// 叶子层在区间结束(steps+1)时投递，保证不会早于数据的到期时间；
+		// 级联层要在区间开始(steps)时就下沉，见 fireCascadingSlot.
+		ticks := steps + 1
+		if tw.cascading {
+			ticks = steps
+		}
+		tw.delayQueue.offer(index, currentStart.Add(time.Duration(ticks)*tw.tickTime))
+	}
+
 	tw.idSlotMap.Lock()
-	tw.idSlotMap.Data[data] = index
+	tw.idSlotMap.Data[data] = slotRef{wheel: tw, index: index}
 	tw.idSlotMap.Unlock()
 
 	return nil
@@ -139,27 +351,25 @@ func (tw *TimerWheel) ScheduleIn(in time.Duration, data interface{}) error {
 
 // Remove 移除一个schedule的数据.
 func (tw *TimerWheel) Remove(data interface{}) {
-	tw.idSlotMap.Lock()
-	index, ok := tw.idSlotMap.Data[data]
-	tw.idSlotMap.Unlock()
-	if !ok {
-		return
-	}
+	tw.removeTimer(data)
+}
 
-	slot := tw.slots[index]
-	slot.Lock()
-	delete(slot.Data, index)
-	slot.Unlock()
+// slotRef 记录一个数据当前所在的时间轮以及槽位，层级时间轮下数据可能位于
+// 任意一级的时间轮中，idSlotMap 在所有层级间共享，因此 Remove 不需要关心
+// 数据具体被级联到了哪一层.
+type slotRef struct {
+	wheel *TimerWheel
+	index int
 }
 
-// SyncBoolMap 线程安全的bool map.
-type SyncBoolMap struct {
-	Data map[interface{}]bool
+// SyncSlotMap 线程安全的 data -> slotRef map.
+type SyncSlotMap struct {
+	Data map[interface{}]slotRef
 	sync.RWMutex
 }
 
-// SyncIntMap 线程安全的int map.
-type SyncIntMap struct {
-	Data map[interface{}]int
+// SyncTimeMap 线程安全的 data -> 到期时间 map.
+type SyncTimeMap struct {
+	Data map[interface{}]time.Time
 	sync.RWMutex
 }
@@ -0,0 +1,80 @@
+package fasttimerwheel
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestScheduleAtFiresExpiredDataFunc 验证基本的schedule/expire流程会按时触发
+// expiredDataFunc，并且过期的数据能在slot里正确拿到.
+func TestScheduleAtFiresExpiredDataFunc(t *testing.T) {
+	fired := make(chan interface{}, 1)
+	tw := New(10*time.Millisecond, 8, 0, func(start time.Time, slot Slot) {
+		for data := range slot.Data {
+			fired <- data
+		}
+	})
+	tw.Start()
+	defer tw.Stop()
+
+	if err := tw.ScheduleIn(20*time.Millisecond, "foo"); err != nil {
+		t.Fatalf("ScheduleIn: %v", err)
+	}
+
+	select {
+	case data := <-fired:
+		if data != "foo" {
+			t.Fatalf("got %v, want foo", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expiredDataFunc never fired")
+	}
+}
+
+// TestAfterFuncConcurrentSchedule 并发地从多个goroutine调用AfterFunc/ScheduleAt，
+// 用来在 go test -race 下暴露 CurrentStartTime/CurrentIndex 的并发读写问题.
+func TestAfterFuncConcurrentSchedule(t *testing.T) {
+	tw := New(5*time.Millisecond, 16, 0, nil)
+	tw.Start()
+	defer tw.Stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			done := make(chan struct{})
+			tw.AfterFunc(10*time.Millisecond, func() { close(done) })
+			<-done
+		}()
+	}
+	wg.Wait()
+}
+
+// TestHierarchicalCascade 验证超出单层时间轮范围的数据会被级联到上一级时间轮，
+// 并且最终仍然能被正确触发（回归 bucket cascading 的到期时机问题）.
+func TestHierarchicalCascade(t *testing.T) {
+	fired := make(chan interface{}, 1)
+	tw := NewHierarchical(10*time.Millisecond, 4, 3, 0, func(start time.Time, slot Slot) {
+		for data := range slot.Data {
+			fired <- data
+		}
+	})
+	tw.Start()
+	defer tw.Stop()
+
+	// 4个槽、tick=10ms，单层最多覆盖40ms，这里调度到120ms之后，必然会级联到上一级.
+	if err := tw.ScheduleIn(120*time.Millisecond, "bar"); err != nil {
+		t.Fatalf("ScheduleIn: %v", err)
+	}
+
+	select {
+	case data := <-fired:
+		if data != "bar" {
+			t.Fatalf("got %v, want bar", data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("cascaded data never fired")
+	}
+}